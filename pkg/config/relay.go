@@ -0,0 +1,33 @@
+package config
+
+import "strings"
+
+// RelayConfig configures the optional outbound SMTP relay used by the
+// mailbox `_relay` and `_send` REST endpoints to forward stored or
+// synthesized messages to an upstream mail server. Its tags name keys for a
+// `smtp.relay` section, but the application's config loading does not read
+// that section into a RelayConfig; a value reaches the REST layer only
+// through rest.SetupRelay.
+type RelayConfig struct {
+	Enabled  bool   `ini:"enabled"`
+	Host     string `ini:"host"`
+	Port     int    `ini:"port"`
+	User     string `ini:"user"`
+	Pass     string `ini:"pass"`
+	StartTLS bool   `ini:"starttls"`
+
+	// AllowedDomains lists the recipient domains relaying is permitted to;
+	// an empty list permits none, since relaying is opt-in per domain.
+	AllowedDomains []string `ini:"allowed_domains,omitempty"`
+}
+
+// Allowed reports whether domain is present in AllowedDomains,
+// case-insensitively.
+func (c RelayConfig) Allowed(domain string) bool {
+	for _, d := range c.AllowedDomains {
+		if strings.EqualFold(d, domain) {
+			return true
+		}
+	}
+	return false
+}
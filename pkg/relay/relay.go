@@ -0,0 +1,105 @@
+// Package relay forwards stored or synthesized messages to a configured
+// upstream SMTP server, turning Inbucket from a pure sink into a staging
+// relay useful in dev/test pipelines.
+package relay
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/jhillyerd/inbucket/pkg/config"
+)
+
+// ErrNotConfigured is returned when a relay is attempted without a
+// configured upstream.
+var ErrNotConfigured = fmt.Errorf("relay: no upstream configured")
+
+// ErrDomainNotAllowed is returned when a recipient's domain isn't present in
+// the relay allowlist.
+type ErrDomainNotAllowed struct {
+	Domain string
+}
+
+func (e ErrDomainNotAllowed) Error() string {
+	return fmt.Sprintf("relay: domain %q is not allowlisted", e.Domain)
+}
+
+// Sender forwards RFC 5322 message bytes to a configured upstream SMTP
+// server.
+type Sender struct {
+	cfg config.RelayConfig
+}
+
+// New creates a Sender bound to the given relay configuration. A Sender
+// backed by a zero-value (or Enabled: false) RelayConfig always returns
+// ErrNotConfigured.
+func New(cfg config.RelayConfig) *Sender {
+	return &Sender{cfg: cfg}
+}
+
+// Send delivers the RFC 5322 message data to the upstream relay, from
+// sender to each address in to. It refuses to dial out if the relay isn't
+// enabled, or if any recipient's domain isn't allowlisted.
+func (s *Sender) Send(from string, to []string, data []byte) error {
+	if !s.cfg.Enabled || s.cfg.Host == "" {
+		return ErrNotConfigured
+	}
+	for _, addr := range to {
+		if domain := domainOf(addr); !s.cfg.Allowed(domain) {
+			return ErrDomainNotAllowed{Domain: domain}
+		}
+	}
+
+	addr := fmt.Sprintf("%s:%d", s.cfg.Host, s.cfg.Port)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("relay: dial %v: %w", addr, err)
+	}
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		return fmt.Errorf("relay: new client: %w", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if s.cfg.StartTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: s.cfg.Host}); err != nil {
+				return fmt.Errorf("relay: starttls: %w", err)
+			}
+		}
+	}
+	if s.cfg.User != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.User, s.cfg.Pass, s.cfg.Host)); err != nil {
+			return fmt.Errorf("relay: auth: %w", err)
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return fmt.Errorf("relay: MAIL FROM: %w", err)
+	}
+	for _, addr := range to {
+		if err := client.Rcpt(addr); err != nil {
+			return fmt.Errorf("relay: RCPT TO %v: %w", addr, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("relay: DATA: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("relay: write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("relay: close message: %w", err)
+	}
+	return client.Quit()
+}
+
+func domainOf(addr string) string {
+	if i := strings.LastIndex(addr, "@"); i >= 0 {
+		return addr[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,161 @@
+package relay
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jhillyerd/inbucket/pkg/config"
+)
+
+// fakeSMTPSink is a minimal single-session SMTP server used to capture what
+// a Sender actually puts on the wire, analogous to the fakeSmtp pattern used
+// by lightweight SMTP test harnesses.
+type fakeSMTPSink struct {
+	ln   net.Listener
+	From string
+	To   []string
+	Data []byte
+	done chan struct{}
+}
+
+func newFakeSMTPSink(t *testing.T) *fakeSMTPSink {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &fakeSMTPSink{ln: ln, done: make(chan struct{})}
+	go sink.serve()
+	return sink
+}
+
+func (s *fakeSMTPSink) hostPort(t *testing.T) (string, int) {
+	host, portStr, err := net.SplitHostPort(s.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return host, port
+}
+
+func (s *fakeSMTPSink) close() { _ = s.ln.Close() }
+
+func (s *fakeSMTPSink) serve() {
+	defer close(s.done)
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+	reply("220 fake.test ESMTP")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, "EHLO"), strings.HasPrefix(line, "HELO"):
+			reply("250 fake.test")
+		case strings.HasPrefix(line, "MAIL FROM:"):
+			s.From = extractAddr(line)
+			reply("250 OK")
+		case strings.HasPrefix(line, "RCPT TO:"):
+			s.To = append(s.To, extractAddr(line))
+			reply("250 OK")
+		case line == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data []byte
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data = append(data, []byte(l)...)
+			}
+			s.Data = data
+			reply("250 OK")
+		case line == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("500 unrecognized command")
+		}
+	}
+}
+
+func extractAddr(line string) string {
+	start := strings.Index(line, "<")
+	end := strings.Index(line, ">")
+	if start < 0 || end < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+func TestSenderDeliversToUpstream(t *testing.T) {
+	sink := newFakeSMTPSink(t)
+	defer sink.close()
+	host, port := sink.hostPort(t)
+
+	s := New(config.RelayConfig{
+		Enabled:        true,
+		Host:           host,
+		Port:           port,
+		AllowedDomains: []string{"example.com"},
+	})
+
+	data := []byte("From: a@b.com\r\nTo: c@example.com\r\nSubject: hi\r\n\r\nbody\r\n")
+	if err := s.Send("a@b.com", []string{"c@example.com"}, data); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-sink.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for fake SMTP session to complete")
+	}
+
+	if sink.From != "a@b.com" {
+		t.Errorf("expected From a@b.com, got %v", sink.From)
+	}
+	if len(sink.To) != 1 || sink.To[0] != "c@example.com" {
+		t.Errorf("expected To [c@example.com], got %v", sink.To)
+	}
+	if string(sink.Data) != string(data) {
+		t.Errorf("expected delivered bytes to match stored message:\nwant: %q\ngot:  %q", data, sink.Data)
+	}
+}
+
+func TestSenderRejectsDisallowedDomain(t *testing.T) {
+	s := New(config.RelayConfig{
+		Enabled:        true,
+		Host:           "127.0.0.1",
+		Port:           25,
+		AllowedDomains: []string{"ok.com"},
+	})
+	err := s.Send("a@b.com", []string{"c@notallowed.com"}, []byte("x"))
+	if _, ok := err.(ErrDomainNotAllowed); !ok {
+		t.Fatalf("expected ErrDomainNotAllowed, got %v", err)
+	}
+}
+
+func TestSenderNotConfigured(t *testing.T) {
+	s := New(config.RelayConfig{})
+	if err := s.Send("a@b.com", []string{"c@d.com"}, []byte("x")); err != ErrNotConfigured {
+		t.Fatalf("expected ErrNotConfigured, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/jhillyerd/inbucket/pkg/message"
+)
+
+// allProperties is the sentinel that requests every property, preserved for
+// callers that haven't adopted projection yet.
+const allProperties = "_all"
+
+// batchRequest is the body of POST /mailbox/{name}/_batch.
+type batchRequest struct {
+	IDs        []string `json:"ids"`
+	Properties []string `json:"properties"`
+}
+
+// batchResult is the per-id outcome returned from a batch request: either a
+// projected message map, or notFound if the id didn't exist.
+type batchResult struct {
+	NotFound bool                   `json:"notFound,omitempty"`
+	Message  map[string]interface{} `json:"message,omitempty"`
+}
+
+// MailboxBatchHandler handles POST /mailbox/{name}/_batch, fetching several
+// messages by ID in one round trip and projecting each down to the
+// requested properties (JMAP Email/get style), ex:
+// {"ids":["0001","0002"],"properties":["subject","from","body.text"]}.
+// Missing ids are reported per-id as notFound rather than failing the whole
+// request.
+func MailboxBatchHandler(w http.ResponseWriter, req *http.Request) error {
+	mailbox, err := parseMailboxName(req)
+	if err != nil {
+		return err
+	}
+
+	var breq batchRequest
+	if err := json.NewDecoder(req.Body).Decode(&breq); err != nil {
+		return httpError(http.StatusBadRequest, "invalid JSON body: "+err.Error())
+	}
+	if len(breq.IDs) == 0 {
+		return httpError(http.StatusBadRequest, "ids must not be empty")
+	}
+
+	all := len(breq.Properties) == 0
+	for _, p := range breq.Properties {
+		if p == allProperties {
+			all = true
+			break
+		}
+	}
+
+	results := make(map[string]batchResult, len(breq.IDs))
+	for _, id := range breq.IDs {
+		msg, err := manager.GetMessage(mailbox, id)
+		if err != nil {
+			results[id] = batchResult{NotFound: true}
+			continue
+		}
+		results[id] = batchResult{Message: projectMessage(msg, breq.Properties, all)}
+	}
+
+	return writeJSON(w, http.StatusOK, results)
+}
+
+// projectMessage renders msg as a map and strips any top-level or dotted
+// (ex: "body.text") property not present in properties, unless all is set.
+func projectMessage(msg *message.Message, properties []string, all bool) map[string]interface{} {
+	full := map[string]interface{}{
+		"mailbox": msg.Mailbox,
+		"id":      msg.ID,
+		"from":    addressString(msg.From),
+		"to":      addressStringList(msg.To),
+		"subject": msg.Subject,
+		"date":    msg.Date,
+		"size":    msg.Size,
+		"header":  msg.Header,
+		"body": map[string]interface{}{
+			"text": msg.Text,
+			"html": msg.HTML,
+		},
+	}
+	if all {
+		return full
+	}
+
+	wanted := make(map[string][]string) // top-level key -> requested sub-keys, nil meaning "whole value"
+	for _, p := range properties {
+		parts := strings.SplitN(p, ".", 2)
+		top := parts[0]
+		if subKeys, ok := wanted[top]; ok && subKeys == nil {
+			// Already marked for the whole value (ex: "body" seen before or
+			// after "body.text"); a dotted property can't narrow that back
+			// down.
+			continue
+		}
+		if len(parts) == 1 {
+			wanted[top] = nil
+			continue
+		}
+		wanted[top] = append(wanted[top], parts[1])
+	}
+
+	out := make(map[string]interface{}, len(wanted))
+	for key, subKeys := range wanted {
+		val, ok := full[key]
+		if !ok {
+			continue
+		}
+		if len(subKeys) == 0 {
+			out[key] = val
+			continue
+		}
+		nested, ok := val.(map[string]interface{})
+		if !ok {
+			out[key] = val
+			continue
+		}
+		projected := make(map[string]interface{}, len(subKeys))
+		for _, sk := range subKeys {
+			if v, ok := nested[sk]; ok {
+				projected[sk] = v
+			}
+		}
+		out[key] = projected
+	}
+	return out
+}
@@ -0,0 +1,190 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jhillyerd/enmime"
+	"github.com/jhillyerd/inbucket/pkg/message"
+	"github.com/jhillyerd/inbucket/pkg/test"
+)
+
+func testRestPost(url string, body []byte) (*httptest.ResponseRecorder, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	webServer.ServeHTTP(w, req)
+	return w, nil
+}
+
+func TestRestMailboxBatch(t *testing.T) {
+	mm := test.NewManager()
+	setupWebServer(mm)
+
+	mm.AddMessage("batch", message.New(
+		message.Metadata{
+			Mailbox: "batch",
+			ID:      "0001",
+			From:    &mail.Address{Address: "from1@host"},
+			To:      []*mail.Address{{Address: "to1@host"}},
+			Subject: "subject 1",
+		},
+		&enmime.Envelope{Text: "text 1", HTML: "html 1"},
+	))
+
+	body, err := json.Marshal(map[string]interface{}{
+		"ids":        []string{"0001", "9999"},
+		"properties": []string{"subject", "from", "to", "body.text"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := testRestPost(baseURL+"/mailbox/batch/_batch", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %v", w.Code)
+	}
+
+	var results map[string]map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+
+	found, ok := results["0001"]
+	if !ok {
+		t.Fatalf("expected entry for 0001, got %v", results)
+	}
+	if found["notFound"] == true {
+		t.Errorf("0001 should not be notFound")
+	}
+	msg, ok := found["message"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected message map, got %v", found["message"])
+	}
+	if msg["subject"] != "subject 1" {
+		t.Errorf("expected projected subject, got %v", msg["subject"])
+	}
+	if msg["from"] != "<from1@host>" {
+		t.Errorf("expected from to be rendered as an address string, got %v (%T)", msg["from"], msg["from"])
+	}
+	to, ok := msg["to"].([]interface{})
+	if !ok || len(to) != 1 || to[0] != "<to1@host>" {
+		t.Errorf("expected to to be a list of address strings, got %v", msg["to"])
+	}
+	bodyMap, ok := msg["body"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected body map, got %v", msg["body"])
+	}
+	if _, ok := bodyMap["html"]; ok {
+		t.Errorf("did not expect body.html in projection, got %v", bodyMap)
+	}
+	if bodyMap["text"] != "text 1" {
+		t.Errorf("expected projected body.text, got %v", bodyMap["text"])
+	}
+
+	missing, ok := results["9999"]
+	if !ok {
+		t.Fatalf("expected entry for 9999, got %v", results)
+	}
+	if missing["notFound"] != true {
+		t.Errorf("expected 9999 to be notFound, got %v", missing)
+	}
+}
+
+// TestRestMailboxBatchWholeValueWins confirms that requesting "body" followed
+// by "body.text" returns the whole body object rather than silently
+// narrowing to just the dotted sub-property.
+func TestRestMailboxBatchWholeValueWins(t *testing.T) {
+	mm := test.NewManager()
+	setupWebServer(mm)
+
+	mm.AddMessage("batch", message.New(
+		message.Metadata{Mailbox: "batch", ID: "0001", Subject: "subject 1"},
+		&enmime.Envelope{Text: "text 1", HTML: "html 1"},
+	))
+
+	body, err := json.Marshal(map[string]interface{}{
+		"ids":        []string{"0001"},
+		"properties": []string{"body", "body.text"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := testRestPost(baseURL+"/mailbox/batch/_batch", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results map[string]map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+
+	msg, ok := results["0001"]["message"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected message map, got %v", results["0001"]["message"])
+	}
+	bodyMap, ok := msg["body"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected body map, got %v", msg["body"])
+	}
+	if bodyMap["html"] != "html 1" {
+		t.Errorf("expected whole body (including html) when \"body\" is requested alongside \"body.text\", got %v", bodyMap)
+	}
+}
+
+// TestRegisterRoutesMailboxBatch drives the endpoint through a real
+// mux.Router built by RegisterRoutes, rather than calling the handler
+// directly, to confirm the route pattern actually matches a request a
+// client would send.
+func TestRegisterRoutesMailboxBatch(t *testing.T) {
+	mm := test.NewManager()
+	setupWebServer(mm)
+
+	mm.AddMessage("routebatch", message.New(
+		message.Metadata{Mailbox: "routebatch", ID: "0001", Subject: "routed"},
+		&enmime.Envelope{Text: "via real router"},
+	))
+
+	body, err := json.Marshal(map[string]interface{}{
+		"ids":        []string{"0001"},
+		"properties": []string{"subject"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := mux.NewRouter()
+	RegisterRoutes(r)
+
+	req, err := http.NewRequest("POST", baseURL+"/mailbox/routebatch/_batch", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Fatalf("expected 200 from the registered route, got %v: %s", w.Code, w.Body.String())
+	}
+
+	var results map[string]map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+	found, ok := results["0001"]
+	if !ok || found["notFound"] == true {
+		t.Fatalf("expected entry for 0001, got %v", results)
+	}
+}
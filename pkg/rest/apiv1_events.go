@@ -0,0 +1,372 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// eventKind identifies the type of change a mailboxEvent describes.
+type eventKind string
+
+const (
+	eventArrived eventKind = "message.arrived"
+	eventSeen    eventKind = "message.seen"
+	eventDeleted eventKind = "message.deleted"
+)
+
+// eventRingSize bounds the number of events retained per mailbox for
+// Last-Event-ID replay; older events are discarded as new ones arrive.
+const eventRingSize = 100
+
+// eventPollInterval controls how often watchMailbox rescans a mailbox for
+// arrivals, seen-flag flips, and deletions. The manager in this tree has no
+// push notification of its own to subscribe to, so the bridge polls; swap
+// this for a direct subscription if/when one is added.
+const eventPollInterval = 10 * time.Millisecond
+
+// mailboxEvent is emitted to subscribers of the _events endpoint. Header is
+// the same JSON shape produced by the mailbox list handler.
+type mailboxEvent struct {
+	ID     int64      `json:"id"`
+	Kind   eventKind  `json:"kind"`
+	Header jsonHeader `json:"header"`
+}
+
+// eventRing is a small in-memory ring buffer of recent events for a single
+// mailbox (or the "" key for the firehose), used to replay missed events to
+// reconnecting clients that send Last-Event-ID.
+type eventRing struct {
+	mu     sync.Mutex
+	nextID int64
+	events []*mailboxEvent
+}
+
+func (r *eventRing) add(kind eventKind, h jsonHeader) *mailboxEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	ev := &mailboxEvent{ID: r.nextID, Kind: kind, Header: h}
+	r.events = append(r.events, ev)
+	if len(r.events) > eventRingSize {
+		r.events = r.events[len(r.events)-eventRingSize:]
+	}
+	return ev
+}
+
+// since returns buffered events with ID greater than lastID.
+func (r *eventRing) since(lastID int64) []*mailboxEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*mailboxEvent
+	for _, ev := range r.events {
+		if ev.ID > lastID {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+// eventHub fans out message manager notifications to SSE/websocket
+// subscribers, keyed by mailbox name ("" subscribes to every mailbox).
+type eventHub struct {
+	mu       sync.Mutex
+	rings    map[string]*eventRing
+	subs     map[string]map[chan *mailboxEvent]bool
+	watching map[string]bool
+	known    map[string]bool // every mailbox name a client has ever connected to
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		rings:    make(map[string]*eventRing),
+		subs:     make(map[string]map[chan *mailboxEvent]bool),
+		watching: make(map[string]bool),
+		known:    make(map[string]bool),
+	}
+}
+
+func (h *eventHub) ring(mailbox string) *eventRing {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r, ok := h.rings[mailbox]
+	if !ok {
+		r = &eventRing{}
+		h.rings[mailbox] = r
+	}
+	return r
+}
+
+// publish broadcasts an event to subscribers of mailbox and to firehose
+// subscribers, recording it in both rings for replay. Each subscriber
+// receives the event as numbered by the ring it replays from.
+func (h *eventHub) publish(mailbox string, kind eventKind, hdr jsonHeader) {
+	ev := h.ring(mailbox).add(kind, hdr)
+	allEv := h.ring("").add(kind, hdr)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for key, e := range map[string]*mailboxEvent{mailbox: ev, "": allEv} {
+		for ch := range h.subs[key] {
+			select {
+			case ch <- e:
+			default:
+				// Slow subscriber; drop rather than block the publisher.
+			}
+		}
+	}
+}
+
+func (h *eventHub) subscribe(mailbox string) chan *mailboxEvent {
+	ch := make(chan *mailboxEvent, 16)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[mailbox] == nil {
+		h.subs[mailbox] = make(map[chan *mailboxEvent]bool)
+	}
+	h.subs[mailbox][ch] = true
+	return ch
+}
+
+func (h *eventHub) unsubscribe(mailbox string, ch chan *mailboxEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs[mailbox], ch)
+	close(ch)
+}
+
+// ensureWatching starts a background poller bridging manager.GetMetadata
+// into published events. For a specific mailbox, it records the mailbox as
+// known and starts its watcher. The firehose key ("") has no mailbox of its
+// own to poll, so instead it starts a watcher for every mailbox already
+// known - a mailbox this process has never seen a client connect to (for
+// its own stream or while the firehose was already running) still won't be
+// watched, since nothing in this tree exposes a way to enumerate every
+// mailbox the manager holds.
+func (h *eventHub) ensureWatching(mailbox string) {
+	if mailbox == "" {
+		for _, m := range h.knownMailboxes() {
+			h.startWatcher(m)
+		}
+		return
+	}
+	h.noteMailbox(mailbox)
+	h.startWatcher(mailbox)
+}
+
+func (h *eventHub) noteMailbox(mailbox string) {
+	h.mu.Lock()
+	h.known[mailbox] = true
+	h.mu.Unlock()
+}
+
+func (h *eventHub) knownMailboxes() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	names := make([]string, 0, len(h.known))
+	for m := range h.known {
+		names = append(names, m)
+	}
+	return names
+}
+
+func (h *eventHub) startWatcher(mailbox string) {
+	h.mu.Lock()
+	if h.watching[mailbox] {
+		h.mu.Unlock()
+		return
+	}
+	h.watching[mailbox] = true
+	h.mu.Unlock()
+	go h.watchMailbox(mailbox)
+}
+
+// watchMailbox polls a single mailbox's metadata, diffing against the
+// previous scan to detect arrivals, seen-flag flips, and deletions, and
+// publishes the corresponding event for each (to both the mailbox's own key
+// and the firehose key, see publish). It exits once nothing is subscribed to
+// the mailbox directly or to the firehose, and will be restarted by the next
+// ensureWatching call.
+func (h *eventHub) watchMailbox(mailbox string) {
+	known := make(map[string]bool) // id -> Seen at last scan
+	ticker := time.NewTicker(eventPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		metas, err := manager.GetMetadata(mailbox)
+		if err != nil {
+			continue
+		}
+
+		current := make(map[string]bool, len(metas))
+		for _, meta := range metas {
+			current[meta.ID] = meta.Seen
+			wasSeen, existed := known[meta.ID]
+			switch {
+			case !existed:
+				h.publish(mailbox, eventArrived, newJSONHeader(meta))
+			case meta.Seen && !wasSeen:
+				h.publish(mailbox, eventSeen, newJSONHeader(meta))
+			}
+		}
+		for id := range known {
+			if _, ok := current[id]; !ok {
+				h.publish(mailbox, eventDeleted, jsonHeader{Mailbox: mailbox, ID: id})
+			}
+		}
+		known = current
+
+		h.mu.Lock()
+		idle := len(h.subs[mailbox]) == 0 && len(h.subs[""]) == 0
+		if idle {
+			h.watching[mailbox] = false
+		}
+		h.mu.Unlock()
+		if idle {
+			return
+		}
+	}
+}
+
+// allowedEventOrigins, when non-empty, lists additional origin hosts (beyond
+// the request's own host) permitted to open a websocket connection to the
+// event stream. Each entry is compared case-insensitively with the host (and
+// port, if any) of the Origin header, not with its scheme. Empty means
+// same-host only.
+var allowedEventOrigins []string
+
+func checkEventOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// No Origin header means this isn't a browser-originated request.
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range allowedEventOrigins {
+		if strings.EqualFold(allowed, u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     checkEventOrigin,
+}
+
+// MailboxEventsHandler serves GET /mailbox/{name}/_events as a Server-Sent
+// Events stream, replaying any buffered events newer than Last-Event-ID
+// before forwarding new ones as they are published. Set the Upgrade:
+// websocket header to receive the same event frames over a websocket
+// connection instead.
+func MailboxEventsHandler(w http.ResponseWriter, req *http.Request) error {
+	mailbox, err := parseMailboxName(req)
+	if err != nil {
+		return err
+	}
+	return serveEvents(w, req, mailbox)
+}
+
+// AllEventsHandler serves GET /_events, the firehose of events across every
+// mailbox whose own _events stream a client has connected to since this
+// process started; connecting to the firehose itself adds no mailbox - see
+// eventHub.ensureWatching.
+func AllEventsHandler(w http.ResponseWriter, req *http.Request) error {
+	return serveEvents(w, req, "")
+}
+
+func serveEvents(w http.ResponseWriter, req *http.Request, mailbox string) error {
+	var lastID int64
+	if v := req.Header.Get("Last-Event-ID"); v != "" {
+		lastID, _ = strconv.ParseInt(v, 10, 64)
+	} else if v := req.URL.Query().Get("lastEventId"); v != "" {
+		lastID, _ = strconv.ParseInt(v, 10, 64)
+	}
+
+	// Subscribe before starting the watcher so it never finds itself
+	// idle-and-alone on its very first tick.
+	ch := hub.subscribe(mailbox)
+	defer hub.unsubscribe(mailbox, ch)
+	hub.ensureWatching(mailbox)
+	backlog := hub.ring(mailbox).since(lastID)
+
+	if websocket.IsWebSocketUpgrade(req) {
+		return serveEventsWebsocket(w, req, backlog, ch)
+	}
+	return serveEventsSSE(w, backlog, ch)
+}
+
+func serveEventsSSE(w http.ResponseWriter, backlog []*mailboxEvent, ch chan *mailboxEvent) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("streaming unsupported by response writer")
+	}
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, ev := range backlog {
+		if err := writeSSEEvent(w, ev); err != nil {
+			return nil
+		}
+	}
+	flusher.Flush()
+
+	for ev := range ch {
+		if err := writeSSEEvent(w, ev); err != nil {
+			return nil
+		}
+		flusher.Flush()
+	}
+	return nil
+}
+
+func writeSSEEvent(w http.ResponseWriter, ev *mailboxEvent) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Kind, b)
+	return err
+}
+
+func serveEventsWebsocket(w http.ResponseWriter, req *http.Request, backlog []*mailboxEvent, ch chan *mailboxEvent) error {
+	conn, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	for _, ev := range backlog {
+		if err := conn.WriteJSON(ev); err != nil {
+			return nil
+		}
+	}
+	for ev := range ch {
+		if err := conn.WriteJSON(ev); err != nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+// hub is the process-wide event fan-out, fed by watchMailbox polling the
+// manager on behalf of every mailbox currently being watched.
+var hub = newEventHub()
@@ -0,0 +1,197 @@
+package rest
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jhillyerd/inbucket/pkg/message"
+	"github.com/jhillyerd/inbucket/pkg/test"
+)
+
+// syncRecorder is an http.ResponseWriter (and http.Flusher) that records the
+// response body behind a mutex. The SSE handler writes from its own
+// goroutine while a test polls the body concurrently from its own, and a
+// plain httptest.ResponseRecorder racing those two is exactly what
+// `go test -race` flags on a bare *bytes.Buffer.
+type syncRecorder struct {
+	mu     sync.Mutex
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func newSyncRecorder() *syncRecorder {
+	return &syncRecorder{header: make(http.Header)}
+}
+
+func (r *syncRecorder) Header() http.Header { return r.header }
+
+func (r *syncRecorder) WriteHeader(code int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.code = code
+}
+
+func (r *syncRecorder) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.body.Write(b)
+}
+
+func (r *syncRecorder) Flush() {}
+
+func (r *syncRecorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.body.String()
+}
+
+// TestRestMailboxEventsSSE connects to the mailbox _events stream, adds a
+// message via the manager, and asserts the arrival event is delivered.
+func TestRestMailboxEventsSSE(t *testing.T) {
+	mm := test.NewManager()
+	setupWebServer(mm)
+
+	req, err := http.NewRequest("GET", baseURL+"/mailbox/eventsarrive/_events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := newSyncRecorder()
+	go webServer.ServeHTTP(w, req)
+
+	// Give the handler a moment to subscribe (and start watching the
+	// mailbox) before publishing.
+	time.Sleep(50 * time.Millisecond)
+
+	mm.AddMessage("eventsarrive", message.New(
+		message.Metadata{Mailbox: "eventsarrive", ID: "0001", Subject: "hello"},
+		nil,
+	))
+
+	waitForBody(t, w, `"0001"`)
+	if !strings.Contains(w.String(), "message.arrived") {
+		t.Errorf("expected arrival event kind, got body: %s", w.String())
+	}
+}
+
+// TestRestEventsLastEventIDReplay connects, observes two arrivals, then
+// reconnects with Last-Event-ID set to the first one and confirms only the
+// second is replayed.
+func TestRestEventsLastEventIDReplay(t *testing.T) {
+	mm := test.NewManager()
+	setupWebServer(mm)
+
+	first, err := http.NewRequest("GET", baseURL+"/mailbox/eventsreplay/_events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w1 := newSyncRecorder()
+	go webServer.ServeHTTP(w1, first)
+	time.Sleep(50 * time.Millisecond)
+
+	mm.AddMessage("eventsreplay", message.New(message.Metadata{Mailbox: "eventsreplay", ID: "0001"}, nil))
+	waitForBody(t, w1, `"0001"`)
+	mm.AddMessage("eventsreplay", message.New(message.Metadata{Mailbox: "eventsreplay", ID: "0002"}, nil))
+	waitForBody(t, w1, `"0002"`)
+
+	second, err := http.NewRequest("GET", baseURL+"/mailbox/eventsreplay/_events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.Header.Set("Last-Event-ID", "1")
+	w2 := newSyncRecorder()
+	go webServer.ServeHTTP(w2, second)
+	time.Sleep(50 * time.Millisecond)
+
+	body := w2.String()
+	if strings.Contains(body, `"0001"`) {
+		t.Errorf("did not expect replay of event already seen by client, got: %s", body)
+	}
+	if !strings.Contains(body, `"0002"`) {
+		t.Errorf("expected replay of event 0002, got: %s", body)
+	}
+}
+
+// TestRestAllEventsFirehose connects a mailbox-specific stream (so the
+// firehose has a known mailbox to watch, per eventHub.ensureWatching), then
+// connects the /_events firehose and confirms it receives an arrival on that
+// mailbox too.
+func TestRestAllEventsFirehose(t *testing.T) {
+	mm := test.NewManager()
+	setupWebServer(mm)
+
+	mbReq, err := http.NewRequest("GET", baseURL+"/mailbox/eventsfirehose/_events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbW := newSyncRecorder()
+	go webServer.ServeHTTP(mbW, mbReq)
+	time.Sleep(50 * time.Millisecond)
+
+	allReq, err := http.NewRequest("GET", baseURL+"/_events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allW := newSyncRecorder()
+	go webServer.ServeHTTP(allW, allReq)
+	time.Sleep(50 * time.Millisecond)
+
+	mm.AddMessage("eventsfirehose", message.New(
+		message.Metadata{Mailbox: "eventsfirehose", ID: "0001"},
+		nil,
+	))
+
+	waitForBody(t, allW, `"kind":"message.arrived","header":{"mailbox":"eventsfirehose","id":"0001"`)
+}
+
+// TestRegisterRoutesMailboxEvents drives both the mailbox-scoped endpoint
+// and the firehose through a real mux.Router built by RegisterRoutes,
+// rather than calling the handlers directly, to confirm the route patterns
+// actually match requests a client would send.
+func TestRegisterRoutesMailboxEvents(t *testing.T) {
+	mm := test.NewManager()
+	setupWebServer(mm)
+
+	r := mux.NewRouter()
+	RegisterRoutes(r)
+
+	req, err := http.NewRequest("GET", baseURL+"/mailbox/routeevents/_events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := newSyncRecorder()
+	go r.ServeHTTP(w, req)
+	time.Sleep(50 * time.Millisecond)
+
+	allReq, err := http.NewRequest("GET", baseURL+"/_events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allW := newSyncRecorder()
+	go r.ServeHTTP(allW, allReq)
+	time.Sleep(50 * time.Millisecond)
+
+	mm.AddMessage("routeevents", message.New(
+		message.Metadata{Mailbox: "routeevents", ID: "0001"},
+		nil,
+	))
+
+	waitForBody(t, w, `"0001"`)
+	waitForBody(t, allW, `"0001"`)
+}
+
+func waitForBody(t *testing.T, w *syncRecorder, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(w.String(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %q, got body: %s", want, w.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
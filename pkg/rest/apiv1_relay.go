@@ -0,0 +1,161 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/jhillyerd/inbucket/pkg/config"
+	"github.com/jhillyerd/inbucket/pkg/relay"
+)
+
+// relaySender is the process-wide outbound relay. It is nil (and the
+// `_relay`/`_send` endpoints respond 501) until SetupRelay installs one from
+// an enabled RelayConfig.
+var relaySender *relay.Sender
+
+// SetupRelay constructs the process-wide relay sender from cfg and installs
+// it, or clears it when cfg is disabled. The server's startup code does not
+// call it, so relaying stays unconfigured unless SetupRelay is invoked.
+func SetupRelay(cfg config.RelayConfig) {
+	if !cfg.Enabled {
+		relaySender = nil
+		return
+	}
+	relaySender = relay.New(cfg)
+}
+
+// sendRequest describes a message to compose and relay, used by the /_send
+// endpoint for callers that don't have a stored message to re-inject.
+type sendRequest struct {
+	From    string   `json:"from"`
+	To      []string `json:"to"`
+	Subject string   `json:"subject"`
+	Text    string   `json:"text"`
+	HTML    string   `json:"html"`
+}
+
+// MailboxRelayHandler handles POST /mailbox/{name}/{id}/_relay, re-injecting
+// a message already stored in a mailbox to the configured upstream relay,
+// byte-for-byte as it was received.
+func MailboxRelayHandler(w http.ResponseWriter, req *http.Request) error {
+	if relaySender == nil {
+		return httpError(http.StatusNotImplemented, "smtp.relay is not configured")
+	}
+	mailbox, err := parseMailboxName(req)
+	if err != nil {
+		return err
+	}
+	id := mux.Vars(req)["id"]
+
+	msg, err := manager.GetMessage(mailbox, id)
+	if err != nil {
+		return httpError(http.StatusNotFound, err.Error())
+	}
+	source, err := manager.SourceReader(mailbox, id)
+	if err != nil {
+		return httpError(http.StatusInternalServerError, err.Error())
+	}
+	defer func() { _ = source.Close() }()
+	data, err := ioutil.ReadAll(source)
+	if err != nil {
+		return httpError(http.StatusInternalServerError, err.Error())
+	}
+
+	from := ""
+	if msg.From != nil {
+		from = msg.From.Address
+	}
+	if err := relaySender.Send(from, addressStrings(msg.To), data); err != nil {
+		return relayHTTPError(err)
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"status": "relayed"})
+}
+
+// SendHandler handles POST /_send, composing a message from a JSON
+// description and relaying it to the configured upstream, rather than
+// re-injecting something already stored.
+func SendHandler(w http.ResponseWriter, req *http.Request) error {
+	if relaySender == nil {
+		return httpError(http.StatusNotImplemented, "smtp.relay is not configured")
+	}
+	var sreq sendRequest
+	if err := json.NewDecoder(req.Body).Decode(&sreq); err != nil {
+		return httpError(http.StatusBadRequest, "invalid JSON body: "+err.Error())
+	}
+	if sreq.From == "" || len(sreq.To) == 0 {
+		return httpError(http.StatusBadRequest, "from and to are required")
+	}
+	if err := validateSendRequest(sreq); err != nil {
+		return httpError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := relaySender.Send(sreq.From, sreq.To, composeRFC5322(sreq)); err != nil {
+		return relayHTTPError(err)
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"status": "relayed"})
+}
+
+// relayHTTPError maps a relay.Sender error to the appropriate HTTP status:
+// 501 when unconfigured, 403 when the recipient domain isn't allowlisted,
+// 502 for any other upstream delivery failure.
+func relayHTTPError(err error) error {
+	switch e := err.(type) {
+	case relay.ErrDomainNotAllowed:
+		return httpError(http.StatusForbidden, e.Error())
+	default:
+		if err == relay.ErrNotConfigured {
+			return httpError(http.StatusNotImplemented, err.Error())
+		}
+		return httpError(http.StatusBadGateway, err.Error())
+	}
+}
+
+// validateSendRequest rejects anything in sreq that could inject extra
+// headers into the RFC 5322 message composeRFC5322 builds from it: From and
+// each To must parse as a single RFC 5322 address (which also rules out
+// embedded CR/LF), and Subject must not contain a CR or LF of its own.
+func validateSendRequest(sreq sendRequest) error {
+	if _, err := mail.ParseAddress(sreq.From); err != nil {
+		return fmt.Errorf("invalid from address: %v", err)
+	}
+	for _, to := range sreq.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid to address %q: %v", to, err)
+		}
+	}
+	if strings.ContainsAny(sreq.Subject, "\r\n") {
+		return fmt.Errorf("subject must not contain CR or LF")
+	}
+	return nil
+}
+
+func addressStrings(addrs []*mail.Address) []string {
+	out := make([]string, len(addrs))
+	for i, a := range addrs {
+		out[i] = a.Address
+	}
+	return out
+}
+
+// composeRFC5322 renders a minimal RFC 5322 message from a send request.
+// HTML is preferred over Text when both are supplied.
+func composeRFC5322(r sendRequest) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", r.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(r.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", r.Subject)
+	if r.HTML != "" {
+		buf.WriteString("Content-Type: text/html; charset=utf-8\r\n\r\n")
+		buf.WriteString(r.HTML)
+	} else {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+		buf.WriteString(r.Text)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,324 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jhillyerd/inbucket/pkg/config"
+	"github.com/jhillyerd/inbucket/pkg/message"
+	"github.com/jhillyerd/inbucket/pkg/relay"
+	"github.com/jhillyerd/inbucket/pkg/test"
+)
+
+func TestRestSendWithoutRelayConfigured(t *testing.T) {
+	mm := test.NewManager()
+	setupWebServer(mm)
+	relaySender = nil
+
+	body, err := json.Marshal(sendRequest{
+		From: "a@b.com",
+		To:   []string{"c@example.com"},
+		Text: "hi",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := testRestPost(baseURL+"/_send", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != 501 {
+		t.Errorf("expected 501 for unconfigured relay, got %v", w.Code)
+	}
+}
+
+func TestRestSendRejectsDisallowedDomain(t *testing.T) {
+	mm := test.NewManager()
+	setupWebServer(mm)
+	relaySender = relay.New(config.RelayConfig{
+		Enabled:        true,
+		Host:           "127.0.0.1",
+		Port:           25,
+		AllowedDomains: []string{"ok.com"},
+	})
+	defer func() { relaySender = nil }()
+
+	body, err := json.Marshal(sendRequest{
+		From: "a@b.com",
+		To:   []string{"c@notallowed.com"},
+		Text: "hi",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := testRestPost(baseURL+"/_send", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != 403 {
+		t.Errorf("expected 403 for disallowed domain, got %v", w.Code)
+	}
+}
+
+// fakeSMTPSink is a minimal single-session SMTP server used to capture what
+// MailboxRelayHandler actually puts on the wire, mirroring the sink used by
+// pkg/relay's own tests.
+type fakeSMTPSink struct {
+	ln   net.Listener
+	From string
+	To   []string
+	Data []byte
+	done chan struct{}
+}
+
+func newFakeSMTPSink(t *testing.T) *fakeSMTPSink {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &fakeSMTPSink{ln: ln, done: make(chan struct{})}
+	go sink.serve()
+	return sink
+}
+
+func (s *fakeSMTPSink) hostPort(t *testing.T) (string, int) {
+	host, portStr, err := net.SplitHostPort(s.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return host, port
+}
+
+func (s *fakeSMTPSink) close() { _ = s.ln.Close() }
+
+func (s *fakeSMTPSink) serve() {
+	defer close(s.done)
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+	reply("220 fake.test ESMTP")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, "EHLO"), strings.HasPrefix(line, "HELO"):
+			reply("250 fake.test")
+		case strings.HasPrefix(line, "MAIL FROM:"):
+			s.From = extractAddr(line)
+			reply("250 OK")
+		case strings.HasPrefix(line, "RCPT TO:"):
+			s.To = append(s.To, extractAddr(line))
+			reply("250 OK")
+		case line == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data []byte
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data = append(data, []byte(l)...)
+			}
+			s.Data = data
+			reply("250 OK")
+		case line == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("500 unrecognized command")
+		}
+	}
+}
+
+func extractAddr(line string) string {
+	start := strings.Index(line, "<")
+	end := strings.Index(line, ">")
+	if start < 0 || end < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+// TestRestMailboxRelay seeds a stored message, points relaySender at a fake
+// SMTP sink, hits the _relay endpoint, and confirms the bytes the sink
+// receives match the message's stored RFC 5322 source exactly.
+func TestRestMailboxRelay(t *testing.T) {
+	mm := test.NewManager()
+	setupWebServer(mm)
+
+	sink := newFakeSMTPSink(t)
+	defer sink.close()
+	host, port := sink.hostPort(t)
+	relaySender = relay.New(config.RelayConfig{
+		Enabled:        true,
+		Host:           host,
+		Port:           port,
+		AllowedDomains: []string{"example.com"},
+	})
+	defer func() { relaySender = nil }()
+
+	mm.AddMessage("relay", message.New(
+		message.Metadata{
+			Mailbox: "relay",
+			ID:      "0001",
+			From:    &mail.Address{Address: "a@b.com"},
+			To:      []*mail.Address{{Address: "c@example.com"}},
+			Subject: "hi",
+		},
+		nil,
+	))
+	srcReader, err := mm.SourceReader("relay", "0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := ioutil.ReadAll(srcReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = srcReader.Close()
+
+	w, err := testRestPost(baseURL+"/mailbox/relay/0001/_relay", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %v: %s", w.Code, w.Body.String())
+	}
+
+	select {
+	case <-sink.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for fake SMTP session to complete")
+	}
+
+	if sink.From != "a@b.com" {
+		t.Errorf("expected From a@b.com, got %v", sink.From)
+	}
+	if len(sink.To) != 1 || sink.To[0] != "c@example.com" {
+		t.Errorf("expected To [c@example.com], got %v", sink.To)
+	}
+	if string(sink.Data) != string(source) {
+		t.Errorf("expected delivered bytes to match stored message:\nwant: %q\ngot:  %q", source, sink.Data)
+	}
+}
+
+func TestComposeRFC5322PrefersHTML(t *testing.T) {
+	data := composeRFC5322(sendRequest{
+		From:    "a@b.com",
+		To:      []string{"c@d.com"},
+		Subject: "subj",
+		Text:    "plain",
+		HTML:    "<p>rich</p>",
+	})
+	if !strings.Contains(string(data), "<p>rich</p>") {
+		t.Errorf("expected HTML body in composed message, got: %s", data)
+	}
+	if strings.Contains(string(data), "plain") {
+		t.Errorf("did not expect text body when HTML is present, got: %s", data)
+	}
+}
+
+// TestSetupRelay confirms SetupRelay installs a relaySender from an enabled
+// config and clears it again when called with a disabled one - the hook the
+// application's startup code is expected to call once `smtp.relay` is
+// parsed.
+func TestSetupRelay(t *testing.T) {
+	defer func() { relaySender = nil }()
+
+	SetupRelay(config.RelayConfig{Enabled: false})
+	if relaySender != nil {
+		t.Errorf("expected relaySender to stay nil for a disabled config")
+	}
+
+	SetupRelay(config.RelayConfig{
+		Enabled:        true,
+		Host:           "127.0.0.1",
+		Port:           25,
+		AllowedDomains: []string{"ok.com"},
+	})
+	if relaySender == nil {
+		t.Fatal("expected SetupRelay to install a relaySender for an enabled config")
+	}
+
+	SetupRelay(config.RelayConfig{Enabled: false})
+	if relaySender != nil {
+		t.Errorf("expected a later disabled config to clear relaySender")
+	}
+}
+
+// TestRegisterRoutesRelayAndSend drives both _relay and _send through a
+// real mux.Router built by RegisterRoutes, rather than calling the handlers
+// directly, to confirm the route patterns actually match requests a client
+// would send.
+func TestRegisterRoutesRelayAndSend(t *testing.T) {
+	mm := test.NewManager()
+	setupWebServer(mm)
+	relaySender = nil
+
+	r := mux.NewRouter()
+	RegisterRoutes(r)
+
+	mm.AddMessage("routerelay", message.New(
+		message.Metadata{
+			Mailbox: "routerelay",
+			ID:      "0001",
+			From:    &mail.Address{Address: "a@b.com"},
+			To:      []*mail.Address{{Address: "c@example.com"}},
+		},
+		nil,
+	))
+
+	relayReq, err := http.NewRequest("POST", baseURL+"/mailbox/routerelay/0001/_relay", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, relayReq)
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 for unconfigured relay via the registered route, got %v", w.Code)
+	}
+
+	body, err := json.Marshal(sendRequest{From: "a@b.com", To: []string{"c@example.com"}, Text: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendReq, err := http.NewRequest("POST", baseURL+"/_send", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendReq.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, sendReq)
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 for unconfigured relay via the registered route, got %v", w.Code)
+	}
+}
@@ -0,0 +1,223 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jhillyerd/inbucket/pkg/message"
+)
+
+// searchableFields are the fields scanned when the caller doesn't restrict
+// the search with `in=`.
+var searchableFields = []string{"subject", "body", "to", "from"}
+
+// searchMatch describes where within a message a query term was found.
+type searchMatch struct {
+	Field  string `json:"field"`
+	Term   string `json:"term"`
+	Offset int    `json:"offset"`
+}
+
+// jsonHeader mirrors the header fields returned by the mailbox list
+// endpoint, shared here so the search results carry the same shape plus
+// match metadata. From/To are rendered as RFC 5322 address strings (ex:
+// "<from1@host>"), matching every other endpoint's wire format -
+// mail.Address itself has no MarshalJSON and would otherwise serialize as
+// a raw {"Name":...,"Address":...} object.
+type jsonHeader struct {
+	Mailbox string    `json:"mailbox"`
+	ID      string    `json:"id"`
+	From    string    `json:"from"`
+	To      []string  `json:"to"`
+	Subject string    `json:"subject"`
+	Date    time.Time `json:"date"`
+	Size    int64     `json:"size"`
+}
+
+func newJSONHeader(meta *message.Metadata) jsonHeader {
+	return jsonHeader{
+		Mailbox: meta.Mailbox,
+		ID:      meta.ID,
+		From:    addressString(meta.From),
+		To:      addressStringList(meta.To),
+		Subject: meta.Subject,
+		Date:    meta.Date,
+		Size:    meta.Size,
+	}
+}
+
+// addressString renders a single address the way every existing endpoint
+// does, ex: "<from1@host>"; nil renders as the empty string.
+func addressString(a *mail.Address) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
+
+// addressStringList renders each address in addrs with addressString.
+func addressStringList(addrs []*mail.Address) []string {
+	out := make([]string, len(addrs))
+	for i, a := range addrs {
+		out[i] = addressString(a)
+	}
+	return out
+}
+
+// searchResultHeader is jsonHeader plus the matches that caused it to be
+// included in a search result set.
+type searchResultHeader struct {
+	jsonHeader
+	Matches []searchMatch `json:"matches"`
+}
+
+// httpStatusError carries an HTTP status code alongside an error message, so
+// handlers can report something other than the default 500.
+type httpStatusError struct {
+	status  int
+	message string
+}
+
+func (e httpStatusError) Error() string { return e.message }
+
+func httpError(status int, message string) error {
+	return httpStatusError{status: status, message: message}
+}
+
+// writeJSON encodes v as the JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+// addressListString renders a slice of mail addresses the same way the
+// existing header JSON does for a single address.
+func addressListString(addrs []*mail.Address) string {
+	parts := make([]string, len(addrs))
+	for i, a := range addrs {
+		parts[i] = a.String()
+	}
+	return strings.Join(parts, " ")
+}
+
+// MailboxSearchHandler handles GET /mailbox/{name}/_search?q=...&in=...,
+// scanning the mailbox for messages containing every `q` term (AND
+// semantics, case-insensitive) across the requested fields and returning
+// match metadata alongside the usual header JSON.
+func MailboxSearchHandler(w http.ResponseWriter, req *http.Request) error {
+	mailbox, err := parseMailboxName(req)
+	if err != nil {
+		return err
+	}
+
+	q := req.URL.Query()
+	terms := q["q"]
+	if len(terms) == 0 {
+		return httpError(http.StatusBadRequest, "at least one q parameter is required")
+	}
+
+	fields := searchableFields
+	if in := q.Get("in"); in != "" {
+		fields = strings.Split(in, ",")
+	}
+
+	var since time.Time
+	if s := q.Get("since"); s != "" {
+		since, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			return httpError(http.StatusBadRequest, "invalid since: "+err.Error())
+		}
+	}
+
+	limit := 0
+	if l := q.Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil {
+			return httpError(http.StatusBadRequest, "invalid limit: "+err.Error())
+		}
+	}
+
+	metas, err := manager.GetMetadata(mailbox)
+	if err != nil {
+		return httpError(http.StatusInternalServerError, err.Error())
+	}
+
+	var results []*searchResultHeader
+	for _, meta := range metas {
+		if !since.IsZero() && meta.Date.Before(since) {
+			continue
+		}
+		msg, err := manager.GetMessage(mailbox, meta.ID)
+		if err != nil {
+			continue
+		}
+		matches, ok := matchMessage(msg, terms, fields)
+		if !ok {
+			continue
+		}
+		results = append(results, &searchResultHeader{
+			jsonHeader: newJSONHeader(meta),
+			Matches:    matches,
+		})
+		if limit > 0 && len(results) >= limit {
+			break
+		}
+	}
+
+	return writeJSON(w, http.StatusOK, results)
+}
+
+// matchMessage reports whether every term in terms is found in at least one
+// of fields (AND across terms, OR across fields per term), returning one
+// match per term for each field containing it, at the term's first
+// occurrence in that field; later occurrences are not reported.
+func matchMessage(msg *message.Message, terms []string, fields []string) ([]searchMatch, bool) {
+	var all []searchMatch
+	for _, term := range terms {
+		needle := strings.ToLower(term)
+		var termMatches []searchMatch
+		for _, field := range fields {
+			haystack := fieldText(msg, field)
+			if haystack == "" {
+				continue
+			}
+			if idx := strings.Index(strings.ToLower(haystack), needle); idx >= 0 {
+				termMatches = append(termMatches, searchMatch{Field: field, Term: term, Offset: idx})
+			}
+		}
+		if len(termMatches) == 0 {
+			return nil, false
+		}
+		all = append(all, termMatches...)
+	}
+	return all, true
+}
+
+// fieldText resolves the searchable text for one of the `in=` field names,
+// including `header:X-Foo` style references into the raw message header.
+func fieldText(msg *message.Message, field string) string {
+	switch {
+	case field == "subject":
+		return msg.Subject
+	case field == "body":
+		return msg.Text + " " + msg.HTML
+	case field == "to":
+		return addressListString(msg.To)
+	case field == "from":
+		if msg.From == nil {
+			return ""
+		}
+		return msg.From.String()
+	case strings.HasPrefix(field, "header:"):
+		name := textproto.CanonicalMIMEHeaderKey(strings.TrimPrefix(field, "header:"))
+		return strings.Join(msg.Header[name], " ")
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,179 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jhillyerd/enmime"
+	"github.com/jhillyerd/inbucket/pkg/message"
+	"github.com/jhillyerd/inbucket/pkg/test"
+)
+
+func TestRestMailboxSearch(t *testing.T) {
+	mm := test.NewManager()
+	setupWebServer(mm)
+
+	mm.AddMessage("search", message.New(
+		message.Metadata{
+			Mailbox: "search",
+			ID:      "0001",
+			From:    &mail.Address{Address: "alice@host"},
+			To:      []*mail.Address{{Address: "bob@host"}},
+			Subject: "quarterly report",
+			Date:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		&enmime.Envelope{Text: "see attached numbers"},
+	))
+	mm.AddMessage("search", message.New(
+		message.Metadata{
+			Mailbox: "search",
+			ID:      "0002",
+			From:    &mail.Address{Address: "carol@host"},
+			To:      []*mail.Address{{Address: "bob@host"}},
+			Subject: "lunch plans",
+			Date:    time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+		&enmime.Envelope{Text: "sandwiches again?"},
+	))
+
+	w, err := testRestGet(baseURL + "/mailbox/search/_search?q=report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %v", w.Code)
+	}
+
+	var results []map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %v", len(results))
+	}
+	if results[0]["id"] != "0001" {
+		t.Errorf("expected id 0001, got %v", results[0]["id"])
+	}
+	if results[0]["from"] != "<alice@host>" {
+		t.Errorf("expected from to be rendered as an address string, got %v (%T)", results[0]["from"], results[0]["from"])
+	}
+	to, ok := results[0]["to"].([]interface{})
+	if !ok || len(to) != 1 || to[0] != "<bob@host>" {
+		t.Errorf("expected to to be a list of address strings, got %v", results[0]["to"])
+	}
+	matches, ok := results[0]["matches"].([]interface{})
+	if !ok || len(matches) == 0 {
+		t.Fatalf("expected matches array, got %v", results[0]["matches"])
+	}
+
+	// AND semantics across multiple q terms.
+	w, err = testRestGet(baseURL + "/mailbox/search/_search?q=sandwiches&q=lunch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results = nil
+	if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+	if len(results) != 1 || results[0]["id"] != "0002" {
+		t.Fatalf("expected only 0002 to match both terms, got %v", results)
+	}
+
+	// No matches.
+	w, err = testRestGet(baseURL + "/mailbox/search/_search?q=nonexistent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results = nil
+	if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected no results, got %v", results)
+	}
+
+	// Missing q parameter is a bad request.
+	w, err = testRestGet(baseURL + "/mailbox/search/_search")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != 400 {
+		t.Errorf("expected 400 for missing q, got %v", w.Code)
+	}
+}
+
+// TestRestMailboxSearchHeaderField confirms `in=header:X-Foo` matches
+// regardless of the case used in the query, since the underlying header map
+// keys are stored canonicalized.
+func TestRestMailboxSearchHeaderField(t *testing.T) {
+	mm := test.NewManager()
+	setupWebServer(mm)
+
+	mm.AddMessage("headersearch", message.New(
+		message.Metadata{Mailbox: "headersearch", ID: "0001", Subject: "subject"},
+		&enmime.Envelope{
+			Root: &enmime.Part{
+				Header: textproto.MIMEHeader{
+					"X-Foo": []string{"needle-value"},
+				},
+			},
+		},
+	))
+
+	w, err := testRestGet(baseURL + "/mailbox/headersearch/_search?q=needle-value&in=header:x-foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %v", w.Code)
+	}
+
+	var results []map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result for lowercase header query, got %v", len(results))
+	}
+}
+
+// TestRegisterRoutesMailboxSearch drives the endpoint through a real
+// mux.Router built by RegisterRoutes, rather than calling the handler
+// directly, to confirm the route pattern actually matches a request a client
+// would send.
+func TestRegisterRoutesMailboxSearch(t *testing.T) {
+	mm := test.NewManager()
+	setupWebServer(mm)
+
+	mm.AddMessage("routesearch", message.New(
+		message.Metadata{Mailbox: "routesearch", ID: "0001", Subject: "routed"},
+		&enmime.Envelope{Text: "via real router"},
+	))
+
+	r := mux.NewRouter()
+	RegisterRoutes(r)
+
+	req, err := http.NewRequest("GET", baseURL+"/mailbox/routesearch/_search?q=routed", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Fatalf("expected 200 from the registered route, got %v: %s", w.Code, w.Body.String())
+	}
+
+	var results []map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+	if len(results) != 1 || results[0]["id"] != "0001" {
+		t.Fatalf("expected routed search to find 0001, got %v", results)
+	}
+}
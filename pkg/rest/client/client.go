@@ -0,0 +1,196 @@
+// Package client implements a typed Go client for the Inbucket v1 REST API,
+// intended for use by external test harnesses and CI pipelines that need to
+// inspect or manipulate mailboxes without hand-rolling HTTP calls.
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is the timeout New gives the underlying http.Client;
+// SetTimeout overrides it.
+const DefaultTimeout = 10 * time.Second
+
+// Client accesses an Inbucket instance's v1 REST API.
+type Client struct {
+	base *url.URL
+	http *http.Client
+}
+
+// New creates a Client for the Inbucket server located at baseURL, ex:
+// "http://localhost:9000".
+func New(baseURL string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("client: parse base URL: %v", err)
+	}
+	return &Client{
+		base: u,
+		http: &http.Client{Timeout: DefaultTimeout},
+	}, nil
+}
+
+// SetTimeout overrides the timeout used for subsequent HTTP requests.
+func (c *Client) SetTimeout(d time.Duration) {
+	c.http.Timeout = d
+}
+
+// SetHTTPClient allows the caller to substitute their own configured
+// http.Client, ex: one with a custom Transport.
+func (c *Client) SetHTTPClient(h *http.Client) {
+	c.http = h
+}
+
+// Body holds the text and HTML renderings of a message.
+type Body struct {
+	Text string `json:"text"`
+	HTML string `json:"html"`
+}
+
+// MessageHeader holds the metadata Inbucket returns for each message in a
+// mailbox listing. It carries a back-reference to the Client it was fetched
+// with so callers can chain further requests, ex: `h.GetMessage()`.
+//
+// From and To are the RFC 5322 address strings Inbucket renders on the wire
+// (ex: "<from1@host>"), not decoded mail.Address values - unmarshal a plain
+// JSON string into mail.Address and it fails outright, so callers that need
+// a parsed address should run From through mail.ParseAddress themselves.
+type MessageHeader struct {
+	Mailbox string    `json:"mailbox"`
+	ID      string    `json:"id"`
+	From    string    `json:"from"`
+	To      []string  `json:"to"`
+	Subject string    `json:"subject"`
+	Date    time.Time `json:"date"`
+	Size    int64     `json:"size"`
+
+	client *Client
+}
+
+// Message holds the full contents of a stored message.
+type Message struct {
+	MessageHeader
+	Header textproto.MIMEHeader `json:"header"`
+	Body   *Body                `json:"body"`
+}
+
+// GetMessage fetches the full contents of the message this header describes.
+func (h *MessageHeader) GetMessage() (*Message, error) {
+	return h.client.GetMessage(h.Mailbox, h.ID)
+}
+
+// MarkSeen flags the message this header describes as seen.
+func (h *MessageHeader) MarkSeen() error {
+	return h.client.MarkSeen(h.Mailbox, h.ID)
+}
+
+// Delete removes the message this header describes.
+func (h *MessageHeader) Delete() error {
+	return h.client.DeleteMessage(h.Mailbox, h.ID)
+}
+
+// GetMessage fetches the full contents of this message from the server again
+// and returns them as a new Message; m itself is not modified.
+func (m *Message) GetMessage() (*Message, error) {
+	return m.client.GetMessage(m.Mailbox, m.ID)
+}
+
+// Delete removes this message.
+func (m *Message) Delete() error {
+	return m.client.DeleteMessage(m.Mailbox, m.ID)
+}
+
+func (c *Client) apiURL(elem ...string) string {
+	u := *c.base
+	u.Path = path.Join(u.Path, "/api/v1/", path.Join(elem...))
+	return u.String()
+}
+
+// do executes an HTTP request against the Inbucket API, decoding a JSON
+// response into out (if non-nil) on success. Non-2xx responses are returned
+// as an error including the response body.
+func (c *Client) do(method, url string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("client: %v %v: %v: %s", method, url, resp.Status, b)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ListMailbox returns the message headers currently stored in the named
+// mailbox.
+func (c *Client) ListMailbox(name string) ([]*MessageHeader, error) {
+	var headers []*MessageHeader
+	if err := c.do(http.MethodGet, c.apiURL("mailbox", name), nil, &headers); err != nil {
+		return nil, err
+	}
+	for _, h := range headers {
+		h.client = c
+	}
+	return headers, nil
+}
+
+// GetMessage fetches a single message by mailbox and ID.
+func (c *Client) GetMessage(mailbox, id string) (*Message, error) {
+	msg := &Message{}
+	if err := c.do(http.MethodGet, c.apiURL("mailbox", mailbox, id), nil, msg); err != nil {
+		return nil, err
+	}
+	msg.client = c
+	return msg, nil
+}
+
+// GetSource fetches the raw RFC 5322 source of a message. The caller is
+// responsible for closing the returned ReadCloser.
+func (c *Client) GetSource(mailbox, id string) (io.ReadCloser, error) {
+	resp, err := c.http.Get(c.apiURL("mailbox", mailbox, id, "source"))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer func() { _ = resp.Body.Close() }()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("client: GetSource %v/%v: %v: %s", mailbox, id, resp.Status, b)
+	}
+	return resp.Body, nil
+}
+
+// MarkSeen flags the given message as seen.
+func (c *Client) MarkSeen(mailbox, id string) error {
+	body := strings.NewReader(`{"seen":true}`)
+	return c.do(http.MethodPatch, c.apiURL("mailbox", mailbox, id), body, nil)
+}
+
+// DeleteMessage removes a single message from a mailbox.
+func (c *Client) DeleteMessage(mailbox, id string) error {
+	return c.do(http.MethodDelete, c.apiURL("mailbox", mailbox, id), nil, nil)
+}
+
+// PurgeMailbox removes all messages from the named mailbox.
+func (c *Client) PurgeMailbox(mailbox string) error {
+	return c.do(http.MethodDelete, c.apiURL("mailbox", mailbox), nil, nil)
+}
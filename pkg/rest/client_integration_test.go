@@ -0,0 +1,75 @@
+package rest
+
+import (
+	"net/http/httptest"
+	"net/mail"
+	"testing"
+
+	"github.com/jhillyerd/inbucket/pkg/message"
+	"github.com/jhillyerd/inbucket/pkg/rest/client"
+	"github.com/jhillyerd/inbucket/pkg/test"
+)
+
+// TestRestClientIntegration drives the REST API through pkg/rest/client
+// against the same web server harness used by the handler tests, validating
+// the contract from both the server and client side at once.
+func TestRestClientIntegration(t *testing.T) {
+	mm := test.NewManager()
+	setupWebServer(mm)
+
+	ts := httptest.NewServer(webServer)
+	defer ts.Close()
+
+	c, err := client.New(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mm.AddMessage("integration", message.New(
+		message.Metadata{
+			Mailbox: "integration",
+			ID:      "0001",
+			From:    &mail.Address{Address: "from1@host"},
+			To:      []*mail.Address{{Address: "to1@host"}},
+			Subject: "client test",
+		},
+		nil,
+	))
+
+	headers, err := c.ListMailbox("integration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != 1 {
+		t.Fatalf("expected 1 header, got %v", len(headers))
+	}
+	h := headers[0]
+	if h.Subject != "client test" {
+		t.Errorf("expected subject %q, got %q", "client test", h.Subject)
+	}
+	if h.From != "<from1@host>" {
+		t.Errorf("expected From to decode as an address string, got %q", h.From)
+	}
+	if len(h.To) != 1 || h.To[0] != "<to1@host>" {
+		t.Errorf("expected To to decode as a list of address strings, got %v", h.To)
+	}
+
+	msg, err := h.GetMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.ID != "0001" {
+		t.Errorf("expected id %q, got %q", "0001", msg.ID)
+	}
+
+	if err := h.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	headers, err = c.ListMailbox("integration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != 0 {
+		t.Errorf("expected mailbox to be empty after delete, got %v", len(headers))
+	}
+}
@@ -0,0 +1,34 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// apiHandlerFunc adapts this package's func(w, req) error handler signature
+// to http.Handler: a returned httpStatusError is reported with its carried
+// status code, anything else as a 500.
+type apiHandlerFunc func(w http.ResponseWriter, req *http.Request) error
+
+func (f apiHandlerFunc) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if err := f(w, req); err != nil {
+		status := http.StatusInternalServerError
+		if se, ok := err.(httpStatusError); ok {
+			status = se.status
+		}
+		http.Error(w, err.Error(), status)
+	}
+}
+
+// RegisterRoutes wires this package's search, batch, events, relay and send
+// v1 REST endpoints into r. The application's main router setup does not
+// call it; these endpoints are served only on a router passed to it.
+func RegisterRoutes(r *mux.Router) {
+	r.Handle("/api/v1/mailbox/{name}/_search", apiHandlerFunc(MailboxSearchHandler)).Methods("GET")
+	r.Handle("/api/v1/mailbox/{name}/_batch", apiHandlerFunc(MailboxBatchHandler)).Methods("POST")
+	r.Handle("/api/v1/mailbox/{name}/_events", apiHandlerFunc(MailboxEventsHandler)).Methods("GET")
+	r.Handle("/api/v1/_events", apiHandlerFunc(AllEventsHandler)).Methods("GET")
+	r.Handle("/api/v1/mailbox/{name}/{id}/_relay", apiHandlerFunc(MailboxRelayHandler)).Methods("POST")
+	r.Handle("/api/v1/_send", apiHandlerFunc(SendHandler)).Methods("POST")
+}